@@ -0,0 +1,273 @@
+package beater
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+// row-based binlog event operations, as surfaced on published events.
+const (
+	binlogOpInsert = "insert"
+	binlogOpUpdate = "update"
+	binlogOpDelete = "delete"
+)
+
+// startBinlogStream opens a replication connection to the server and
+// publishes an event per row of each row-based binlog event, in its own
+// goroutine. The
+// syncer is tracked on bt.binlogSyncers so Stop can close it.
+func (bt *Mysqlbeat) startBinlogStream(serverName string, params *config.Server) {
+	bt.binlogWG.Add(1)
+
+	go func() {
+		defer bt.binlogWG.Done()
+
+		if err := bt.runBinlogStream(serverName, params); err != nil {
+			logp.Err("binlog-stream for server %v stopped: %v", serverName, err)
+		}
+	}()
+}
+
+func (bt *Mysqlbeat) runBinlogStream(serverName string, params *config.Server) error {
+	port, err := strconv.ParseUint(params.Port, 10, 16)
+	if err != nil {
+		port = 3306
+	}
+
+	syncerCfg := replication.BinlogSyncerConfig{
+		ServerID: params.ServerID,
+		Flavor:   "mysql",
+		Host:     params.Hostname,
+		Port:     uint16(port),
+		User:     params.Username,
+		Password: params.Password,
+	}
+
+	syncer := replication.NewBinlogSyncer(syncerCfg)
+	bt.binlogMu.Lock()
+	bt.binlogSyncers[serverName] = syncer
+	bt.binlogMu.Unlock()
+
+	pos, gtidSet, err := loadBinlogCheckpoint(checkpointFile(serverName, params))
+	if err != nil {
+		return err
+	}
+	if pos.Name == "" {
+		pos = gomysql.Position{Name: params.BinlogFile, Pos: params.BinlogPos}
+	}
+	if gtidSet == "" {
+		gtidSet = params.GTIDSet
+	}
+
+	var includeTables, excludeTables *regexp.Regexp
+	if params.IncludeTables != "" {
+		if includeTables, err = regexp.Compile(params.IncludeTables); err != nil {
+			return fmt.Errorf("error compiling include_tables: %v", err)
+		}
+	}
+	if params.ExcludeTables != "" {
+		if excludeTables, err = regexp.Compile(params.ExcludeTables); err != nil {
+			return fmt.Errorf("error compiling exclude_tables: %v", err)
+		}
+	}
+
+	var streamer *replication.BinlogStreamer
+	// executed is the GTID set checkpointed after each rows event. It is only
+	// tracked when syncing by GTID; a position-based sync checkpoints file/pos.
+	var executed gomysql.GTIDSet
+	if gtidSet != "" {
+		set, parseErr := gomysql.ParseGTIDSet("mysql", gtidSet)
+		if parseErr != nil {
+			return fmt.Errorf("error parsing gtid_set: %v", parseErr)
+		}
+		executed = set.Clone()
+		streamer, err = syncer.StartSyncGTID(set)
+	} else {
+		streamer, err = syncer.StartSync(pos)
+	}
+	if err != nil {
+		return fmt.Errorf("error starting binlog sync: %v", err)
+	}
+
+	var curGTID, curFile string
+	for {
+		ev, err := streamer.GetEvent(context.Background())
+		if err != nil {
+			select {
+			case <-bt.done:
+				// Stop() closed the syncer; this is a clean shutdown.
+				return nil
+			default:
+				return fmt.Errorf("binlog stream disconnected: %v", err)
+			}
+		}
+
+		switch e := ev.Event.(type) {
+		case *replication.RotateEvent:
+			curFile = string(e.NextLogName)
+
+		case *replication.GTIDEvent:
+			curGTID = fmt.Sprintf("%s:%d", formatGTIDSourceUUID(e.SID), e.GNO)
+			if executed != nil {
+				if err := executed.Update(curGTID); err != nil {
+					logp.Err("error tracking gtid %v for server %v: %v", curGTID, serverName, err)
+				}
+			}
+
+		case *replication.RowsEvent:
+			op, ok := rowsEventOp(ev.Header.EventType)
+			if !ok {
+				continue
+			}
+
+			db := string(e.Table.Schema)
+			table := string(e.Table.Table)
+			fullName := db + "." + table
+
+			if includeTables != nil && !includeTables.MatchString(fullName) {
+				continue
+			}
+			if excludeTables != nil && excludeTables.MatchString(fullName) {
+				continue
+			}
+
+			bt.publishBinlogRows(serverName, db, table, op, e, common.MapStr{
+				"gtid":     curGTID,
+				"log_file": curFile,
+				"log_pos":  ev.Header.LogPos,
+			})
+
+			var checkpointGTIDSet string
+			if executed != nil {
+				checkpointGTIDSet = executed.String()
+			}
+			if err := saveBinlogCheckpoint(checkpointFile(serverName, params), curFile, ev.Header.LogPos, checkpointGTIDSet); err != nil {
+				logp.Err("error saving binlog checkpoint for server %v: %v", serverName, err)
+			}
+		}
+	}
+}
+
+// publishBinlogRows publishes one event per affected row: update events get
+// both before/after, insert only after, delete only before.
+func (bt *Mysqlbeat) publishBinlogRows(serverName, db, table, op string, e *replication.RowsEvent, extra common.MapStr) {
+	now := time.Now()
+
+	publish := func(before, after []interface{}) {
+		event := common.MapStr{
+			"@timestamp": common.Time(now),
+			"type":       queryTypeBinlogStream,
+			"hostname":   serverName,
+			"db":         db,
+			"table":      table,
+			"op":         op,
+			"ts":         common.Time(now),
+		}
+		for k, v := range extra {
+			event[k] = v
+		}
+		if before != nil {
+			event["before"] = before
+		}
+		if after != nil {
+			event["after"] = after
+		}
+		bt.client.PublishEvent(event)
+	}
+
+	switch op {
+	case binlogOpUpdate:
+		for i := 0; i+1 < len(e.Rows); i += 2 {
+			publish(e.Rows[i], e.Rows[i+1])
+		}
+	case binlogOpDelete:
+		for _, row := range e.Rows {
+			publish(row, nil)
+		}
+	default: // insert
+		for _, row := range e.Rows {
+			publish(nil, row)
+		}
+	}
+}
+
+func rowsEventOp(eventType replication.EventType) (string, bool) {
+	switch eventType {
+	case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		return binlogOpInsert, true
+	case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		return binlogOpUpdate, true
+	case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		return binlogOpDelete, true
+	default:
+		return "", false
+	}
+}
+
+// formatGTIDSourceUUID renders a GTIDEvent's SID (the 16 raw source-UUID
+// bytes) as the canonical 8-4-4-4-12 hex string, so curGTID is a real
+// "source-uuid:txn-id" GTID and not just the bare transaction number. That
+// full form is both what's published as the gtid field and what's added to
+// the executed GTID set that saveBinlogCheckpoint persists and
+// gomysql.ParseGTIDSet reads back on restart.
+func formatGTIDSourceUUID(sid []byte) string {
+	if len(sid) != 16 {
+		return hex.EncodeToString(sid)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16])
+}
+
+// checkpointFile returns the path used to persist the last-processed
+// binlog position/GTID for a server, so restarts resume cleanly.
+func checkpointFile(serverName string, params *config.Server) string {
+	if params.CheckpointFile != "" {
+		return params.CheckpointFile
+	}
+	return fmt.Sprintf(".mysqlbeat-%s.binlog-checkpoint", serverName)
+}
+
+// loadBinlogCheckpoint reads a "file pos gtid_set" line written by
+// saveBinlogCheckpoint. A missing file is not an error; it just means
+// there's nothing to resume from yet.
+func loadBinlogCheckpoint(path string) (gomysql.Position, string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return gomysql.Position{}, "", nil
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(string(data)), " ", 3)
+	if len(fields) < 2 {
+		return gomysql.Position{}, "", nil
+	}
+
+	pos, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return gomysql.Position{}, "", fmt.Errorf("error parsing checkpoint file %v: %v", path, err)
+	}
+
+	var gtidSet string
+	if len(fields) == 3 {
+		gtidSet = fields[2]
+	}
+
+	return gomysql.Position{Name: fields[0], Pos: uint32(pos)}, gtidSet, nil
+}
+
+func saveBinlogCheckpoint(path, file string, pos uint32, gtidSet string) error {
+	line := fmt.Sprintf("%s %d %s\n", file, pos, gtidSet)
+	return ioutil.WriteFile(path, []byte(line), 0644)
+}
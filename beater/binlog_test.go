@@ -0,0 +1,104 @@
+package beater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+func TestFormatGTIDSourceUUID(t *testing.T) {
+	sid := []byte{
+		0x12, 0x34, 0x56, 0x78,
+		0x9a, 0xbc,
+		0xde, 0xf0,
+		0x11, 0x22,
+		0x33, 0x44, 0x55, 0x66, 0x77, 0x88,
+	}
+
+	got := formatGTIDSourceUUID(sid)
+	want := "12345678-9abc-def0-1122-334455667788"
+	if got != want {
+		t.Fatalf("formatGTIDSourceUUID = %v, want %v", got, want)
+	}
+}
+
+func TestFormatGTIDSourceUUIDWrongLength(t *testing.T) {
+	got := formatGTIDSourceUUID([]byte{0x01, 0x02})
+	if got != "0102" {
+		t.Fatalf("formatGTIDSourceUUID of a malformed SID = %v, want hex fallback 0102", got)
+	}
+}
+
+func TestRowsEventOp(t *testing.T) {
+	cases := []struct {
+		eventType replication.EventType
+		wantOp    string
+		wantOK    bool
+	}{
+		{replication.WRITE_ROWS_EVENTv2, binlogOpInsert, true},
+		{replication.UPDATE_ROWS_EVENTv2, binlogOpUpdate, true},
+		{replication.DELETE_ROWS_EVENTv2, binlogOpDelete, true},
+		{replication.QUERY_EVENT, "", false},
+	}
+
+	for _, c := range cases {
+		op, ok := rowsEventOp(c.eventType)
+		if op != c.wantOp || ok != c.wantOK {
+			t.Errorf("rowsEventOp(%v) = (%v, %v), want (%v, %v)", c.eventType, op, ok, c.wantOp, c.wantOK)
+		}
+	}
+}
+
+func TestCheckpointFile(t *testing.T) {
+	if got := checkpointFile("server1", &config.Server{}); got != ".mysqlbeat-server1.binlog-checkpoint" {
+		t.Fatalf("checkpointFile with no override = %v", got)
+	}
+	if got := checkpointFile("server1", &config.Server{CheckpointFile: "/tmp/custom-path"}); got != "/tmp/custom-path" {
+		t.Fatalf("checkpointFile with override = %v, want /tmp/custom-path", got)
+	}
+}
+
+func TestSaveAndLoadBinlogCheckpointRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqlbeat-checkpoint-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	if err := saveBinlogCheckpoint(path, "mysql-bin.000042", 12345, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23"); err != nil {
+		t.Fatalf("saveBinlogCheckpoint: %v", err)
+	}
+
+	pos, gtidSet, err := loadBinlogCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadBinlogCheckpoint: %v", err)
+	}
+	if pos.Name != "mysql-bin.000042" || pos.Pos != 12345 {
+		t.Fatalf("pos = %+v, want {mysql-bin.000042 12345}", pos)
+	}
+	if gtidSet != "3e11fa47-71ca-11e1-9e33-c80aa9429562:23" {
+		t.Fatalf("gtidSet = %v, want the saved GTID set", gtidSet)
+	}
+}
+
+func TestLoadBinlogCheckpointMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqlbeat-checkpoint-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pos, gtidSet, err := loadBinlogCheckpoint(filepath.Join(dir, "does-not-exist"))
+	if err != nil {
+		t.Fatalf("expected a missing checkpoint file to not be an error, got: %v", err)
+	}
+	if pos.Name != "" || gtidSet != "" {
+		t.Fatalf("expected zero values for a missing checkpoint file, got pos=%+v gtidSet=%v", pos, gtidSet)
+	}
+}
@@ -0,0 +1,192 @@
+package beater
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Prometheus-style column suffixes. __DELTA (bt.deltaWildcard, configurable)
+// is kept as an alias for __COUNTER for backward compatibility.
+const (
+	counterSuffix   = "__COUNTER"
+	gaugeRateSuffix = "__GAUGE_RATE"
+	histogramSuffix = "__HISTOGRAM"
+)
+
+type deltaKind int
+
+const (
+	deltaKindNone deltaKind = iota
+	deltaKindCounter
+	deltaKindGaugeRate
+)
+
+// deltaState is the last observed value/age for one delta column, plus how
+// many resets a __COUNTER has seen. It replaces the old
+// oldValues/oldValuesAge common.MapStr pair, whose keys weren't namespaced
+// by server and so collided across servers sharing column names.
+type deltaState struct {
+	intValue   int64
+	floatValue float64
+	age        time.Time
+	resetTotal int64
+}
+
+var histogramBucketPattern = regexp.MustCompile(`^(.+)_bucket\{le="([^"]+)"\}$`)
+
+// classifyColumn identifies which Prometheus-style suffix (if any) a column
+// name ends with, returning the kind and the column name with that suffix
+// removed.
+func (bt *Mysqlbeat) classifyColumn(colName string) (kind deltaKind, baseName string) {
+	switch {
+	case strings.HasSuffix(colName, counterSuffix):
+		return deltaKindCounter, strings.TrimSuffix(colName, counterSuffix)
+	case bt.deltaWildcard != "" && strings.HasSuffix(colName, bt.deltaWildcard):
+		return deltaKindCounter, strings.TrimSuffix(colName, bt.deltaWildcard)
+	case strings.HasSuffix(colName, gaugeRateSuffix):
+		return deltaKindGaugeRate, strings.TrimSuffix(colName, gaugeRateSuffix)
+	default:
+		return deltaKindNone, colName
+	}
+}
+
+// histogramColumn reports whether colName is a
+// "<metric>_bucket{le=\"X\"}__HISTOGRAM" column, returning the metric name
+// and the bucket's upper bound.
+func histogramColumn(colName string) (metric string, le float64, ok bool) {
+	if !strings.HasSuffix(colName, histogramSuffix) {
+		return "", 0, false
+	}
+
+	m := histogramBucketPattern.FindStringSubmatch(strings.TrimSuffix(colName, histogramSuffix))
+	if m == nil {
+		return "", 0, false
+	}
+
+	le, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return m[1], le, true
+}
+
+// appendHistogramBucket accumulates one bucket into event[metric]'s
+// values/counts arrays (an Elastic histogram field), keeping buckets sorted
+// by their le bound.
+func appendHistogramBucket(event common.MapStr, metric string, le float64, count int64) {
+	hist, _ := event[metric].(common.MapStr)
+	if hist == nil {
+		hist = common.MapStr{"values": []float64{}, "counts": []int64{}}
+	}
+
+	values := hist["values"].([]float64)
+	counts := hist["counts"].([]int64)
+
+	i := 0
+	for i < len(values) && values[i] < le {
+		i++
+	}
+
+	values = append(values, 0)
+	copy(values[i+1:], values[i:])
+	values[i] = le
+
+	counts = append(counts, 0)
+	copy(counts[i+1:], counts[i:])
+	counts[i] = count
+
+	hist["values"] = values
+	hist["counts"] = counts
+	event[metric] = hist
+}
+
+// deltaKey namespaces delta state by server and query index, on top of the
+// row key and column name. The previous global oldValues/oldValuesAge maps
+// didn't namespace by server, a real bug: two servers scraping a query
+// with the same column names would stomp on each other's state.
+func deltaKey(serverName string, queryIndex int, rowKey, column string) string {
+	return fmt.Sprintf("%s\x00%d\x00%s\x00%s", serverName, queryIndex, rowKey, column)
+}
+
+// resolveCounter computes the per-second rate for a monotonic __COUNTER
+// int64 column. On a reset (new < old) it reports new/delta_seconds as the
+// rate and bumps the column's reset count, rather than silently emitting 0.
+func (bt *Mysqlbeat) resolveCounter(key string, newValue int64, now time.Time) (rate int64, resetTotal int64, hadPrevious bool) {
+	bt.deltaMu.Lock()
+	defer bt.deltaMu.Unlock()
+
+	prev, ok := bt.deltaStates[key]
+	if !ok {
+		bt.deltaStates[key] = &deltaState{intValue: newValue, age: now}
+		return 0, 0, false
+	}
+
+	deltaSeconds := now.Sub(prev.age).Seconds()
+	change := newValue - prev.intValue
+	if newValue < prev.intValue {
+		// Counter reset (process restart, table truncated, etc.)
+		change = newValue
+		prev.resetTotal++
+	}
+
+	rate = roundF2I(float64(change)/deltaSeconds, .5)
+
+	prev.intValue = newValue
+	prev.age = now
+
+	return rate, prev.resetTotal, true
+}
+
+// resolveCounterFloat is resolveCounter for float64-valued counters.
+func (bt *Mysqlbeat) resolveCounterFloat(key string, newValue float64, now time.Time) (rate float64, resetTotal int64, hadPrevious bool) {
+	bt.deltaMu.Lock()
+	defer bt.deltaMu.Unlock()
+
+	prev, ok := bt.deltaStates[key]
+	if !ok {
+		bt.deltaStates[key] = &deltaState{floatValue: newValue, age: now}
+		return 0, 0, false
+	}
+
+	deltaSeconds := now.Sub(prev.age).Seconds()
+	change := newValue - prev.floatValue
+	if newValue < prev.floatValue {
+		change = newValue
+		prev.resetTotal++
+	}
+
+	rate = change / deltaSeconds
+
+	prev.floatValue = newValue
+	prev.age = now
+
+	return rate, prev.resetTotal, true
+}
+
+// resolveGaugeRate computes a signed per-second derivative for a
+// __GAUGE_RATE column. Unlike __COUNTER, a decrease is a legitimate
+// negative rate, not a reset.
+func (bt *Mysqlbeat) resolveGaugeRate(key string, newValue float64, now time.Time) (rate float64, hadPrevious bool) {
+	bt.deltaMu.Lock()
+	defer bt.deltaMu.Unlock()
+
+	prev, ok := bt.deltaStates[key]
+	if !ok {
+		bt.deltaStates[key] = &deltaState{floatValue: newValue, age: now}
+		return 0, false
+	}
+
+	deltaSeconds := now.Sub(prev.age).Seconds()
+	rate = (newValue - prev.floatValue) / deltaSeconds
+
+	prev.floatValue = newValue
+	prev.age = now
+
+	return rate, true
+}
@@ -0,0 +1,155 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestClassifyColumn(t *testing.T) {
+	bt := &Mysqlbeat{deltaWildcard: "__DELTA"}
+
+	cases := []struct {
+		col      string
+		wantKind deltaKind
+		wantBase string
+	}{
+		{"bytes_sent__COUNTER", deltaKindCounter, "bytes_sent"},
+		{"bytes_sent__DELTA", deltaKindCounter, "bytes_sent"},
+		{"connections__GAUGE_RATE", deltaKindGaugeRate, "connections"},
+		{"uptime", deltaKindNone, "uptime"},
+	}
+
+	for _, c := range cases {
+		kind, base := bt.classifyColumn(c.col)
+		if kind != c.wantKind || base != c.wantBase {
+			t.Errorf("classifyColumn(%q) = (%v, %q), want (%v, %q)", c.col, kind, base, c.wantKind, c.wantBase)
+		}
+	}
+}
+
+func TestHistogramColumn(t *testing.T) {
+	metric, le, ok := histogramColumn(`request_duration_seconds_bucket{le="0.5"}__HISTOGRAM`)
+	if !ok || metric != "request_duration_seconds" || le != 0.5 {
+		t.Fatalf("got (%q, %v, %v), want (\"request_duration_seconds\", 0.5, true)", metric, le, ok)
+	}
+
+	if _, _, ok := histogramColumn("request_duration_seconds"); ok {
+		t.Fatal("expected a column with no __HISTOGRAM suffix to not match")
+	}
+
+	if _, _, ok := histogramColumn("not_a_bucket__HISTOGRAM"); ok {
+		t.Fatal("expected a malformed bucket column to not match")
+	}
+
+	if _, _, ok := histogramColumn(`x_bucket{le="not-a-number"}__HISTOGRAM`); ok {
+		t.Fatal("expected a non-numeric le to not match")
+	}
+}
+
+func TestAppendHistogramBucketOrdering(t *testing.T) {
+	event := common.MapStr{}
+
+	appendHistogramBucket(event, "latency", 1.0, 5)
+	appendHistogramBucket(event, "latency", 0.1, 2)
+	appendHistogramBucket(event, "latency", 0.5, 3)
+
+	hist := event["latency"].(common.MapStr)
+	values := hist["values"].([]float64)
+	counts := hist["counts"].([]int64)
+
+	wantValues := []float64{0.1, 0.5, 1.0}
+	wantCounts := []int64{2, 3, 5}
+
+	for i := range wantValues {
+		if values[i] != wantValues[i] {
+			t.Fatalf("values = %v, want %v", values, wantValues)
+		}
+		if counts[i] != wantCounts[i] {
+			t.Fatalf("counts = %v, want %v", counts, wantCounts)
+		}
+	}
+}
+
+func TestDeltaKeyNamespacesByServerAndQuery(t *testing.T) {
+	k1 := deltaKey("server1", 0, "row1", "col")
+	k2 := deltaKey("server2", 0, "row1", "col")
+	k3 := deltaKey("server1", 1, "row1", "col")
+
+	if k1 == k2 {
+		t.Fatal("expected different servers to produce different keys")
+	}
+	if k1 == k3 {
+		t.Fatal("expected different query indices to produce different keys")
+	}
+}
+
+func TestResolveCounter(t *testing.T) {
+	bt := &Mysqlbeat{deltaStates: make(map[string]*deltaState)}
+	t0 := time.Unix(1000, 0)
+	t1 := t0.Add(10 * time.Second)
+	t2 := t1.Add(10 * time.Second)
+
+	if _, _, hadPrevious := bt.resolveCounter("k", 100, t0); hadPrevious {
+		t.Fatal("expected no previous value on first observation")
+	}
+
+	rate, resetTotal, hadPrevious := bt.resolveCounter("k", 150, t1)
+	if !hadPrevious {
+		t.Fatal("expected a previous value on second observation")
+	}
+	if rate != 5 {
+		t.Fatalf("rate = %v, want 5 (50 over 10s)", rate)
+	}
+	if resetTotal != 0 {
+		t.Fatalf("resetTotal = %v, want 0", resetTotal)
+	}
+
+	// Counter reset: new value is lower than the last observed value.
+	rate, resetTotal, hadPrevious = bt.resolveCounter("k", 20, t2)
+	if !hadPrevious {
+		t.Fatal("expected a previous value on third observation")
+	}
+	if rate != 2 {
+		t.Fatalf("rate after reset = %v, want 2 (20 over 10s)", rate)
+	}
+	if resetTotal != 1 {
+		t.Fatalf("resetTotal after reset = %v, want 1", resetTotal)
+	}
+}
+
+func TestResolveCounterFloat(t *testing.T) {
+	bt := &Mysqlbeat{deltaStates: make(map[string]*deltaState)}
+	t0 := time.Unix(1000, 0)
+	t1 := t0.Add(10 * time.Second)
+
+	bt.resolveCounterFloat("k", 10.0, t0)
+
+	rate, resetTotal, hadPrevious := bt.resolveCounterFloat("k", 30.0, t1)
+	if !hadPrevious {
+		t.Fatal("expected a previous value on second observation")
+	}
+	if rate != 2.0 {
+		t.Fatalf("rate = %v, want 2.0 (20 over 10s)", rate)
+	}
+	if resetTotal != 0 {
+		t.Fatalf("resetTotal = %v, want 0", resetTotal)
+	}
+}
+
+func TestResolveGaugeRateAllowsNegative(t *testing.T) {
+	bt := &Mysqlbeat{deltaStates: make(map[string]*deltaState)}
+	t0 := time.Unix(1000, 0)
+	t1 := t0.Add(10 * time.Second)
+
+	bt.resolveGaugeRate("k", 100.0, t0)
+
+	rate, hadPrevious := bt.resolveGaugeRate("k", 50.0, t1)
+	if !hadPrevious {
+		t.Fatal("expected a previous value on second observation")
+	}
+	if rate != -5.0 {
+		t.Fatalf("rate = %v, want -5.0 (a legitimate negative derivative)", rate)
+	}
+}
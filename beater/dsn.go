@@ -0,0 +1,75 @@
+package beater
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+// buildDSN assembles a go-sql-driver mysql.Config for the given server and
+// returns its formatted DSN. This replaces the naive
+// fmt.Sprintf("%v:%v@tcp(%v:%v)/", ...) connection string, which mishandled
+// special characters in usernames/passwords and couldn't express charset,
+// timeouts, unix sockets or IPv6 hosts.
+func buildDSN(serverName string, params *config.Server) (string, error) {
+	cfg := mysql.NewConfig()
+
+	cfg.User = params.Username
+	cfg.Passwd = params.Password
+	cfg.DBName = params.Database
+	cfg.AllowNativePasswords = params.AllowNativePasswords
+
+	switch params.Protocol {
+	case "unix":
+		cfg.Net = "unix"
+		cfg.Addr = params.SocketPath
+	default:
+		port := params.Port
+		if port == "" {
+			port = "3306"
+		}
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(params.Hostname, port)
+	}
+
+	if params.Charset != "" {
+		cfg.Params = map[string]string{"charset": params.Charset}
+	}
+
+	if params.Collation != "" {
+		cfg.Collation = params.Collation
+	}
+
+	if params.Timezone != "" {
+		loc, err := time.LoadLocation(params.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("error parsing timezone for server %v: %v", serverName, err)
+		}
+		cfg.Loc = loc
+	}
+
+	cfg.Timeout = params.ConnectTimeout
+	cfg.ReadTimeout = params.ReadTimeout
+	cfg.WriteTimeout = params.WriteTimeout
+
+	for k, v := range params.Params {
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]string, len(params.Params))
+		}
+		cfg.Params[k] = v
+	}
+
+	tlsParam, err := tlsParamFor(serverName, params)
+	if err != nil {
+		return "", err
+	}
+	if tlsParam != "" {
+		cfg.TLSConfig = tlsParam
+	}
+
+	return cfg.FormatDSN(), nil
+}
@@ -0,0 +1,119 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+func TestBuildDSNTCP(t *testing.T) {
+	dsn, err := buildDSN("server1", &config.Server{
+		Hostname: "db.internal",
+		Port:     "3307",
+		Username: "scraper",
+		Password: "p@ss:w/ord",
+		Database: "information_schema",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("produced an unparseable DSN %q: %v", dsn, err)
+	}
+	if cfg.Net != "tcp" || cfg.Addr != "db.internal:3307" {
+		t.Fatalf("got net=%v addr=%v, want tcp db.internal:3307", cfg.Net, cfg.Addr)
+	}
+	if cfg.User != "scraper" || cfg.Passwd != "p@ss:w/ord" {
+		t.Fatalf("special characters in user/password didn't round-trip: got user=%v passwd=%v", cfg.User, cfg.Passwd)
+	}
+	if cfg.DBName != "information_schema" {
+		t.Fatalf("DBName = %v, want information_schema", cfg.DBName)
+	}
+}
+
+func TestBuildDSNDefaultPort(t *testing.T) {
+	dsn, err := buildDSN("server1", &config.Server{Hostname: "db.internal"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("produced an unparseable DSN %q: %v", dsn, err)
+	}
+	if cfg.Addr != "db.internal:3306" {
+		t.Fatalf("Addr = %v, want the default port 3306", cfg.Addr)
+	}
+}
+
+func TestBuildDSNUnixSocket(t *testing.T) {
+	dsn, err := buildDSN("server1", &config.Server{
+		Protocol:   "unix",
+		SocketPath: "/var/run/mysqld/mysqld.sock",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("produced an unparseable DSN %q: %v", dsn, err)
+	}
+	if cfg.Net != "unix" || cfg.Addr != "/var/run/mysqld/mysqld.sock" {
+		t.Fatalf("got net=%v addr=%v, want unix /var/run/mysqld/mysqld.sock", cfg.Net, cfg.Addr)
+	}
+}
+
+func TestBuildDSNInvalidTimezone(t *testing.T) {
+	_, err := buildDSN("server1", &config.Server{
+		Hostname: "db.internal",
+		Timezone: "Not/A_Real_Zone",
+	})
+	if err == nil {
+		t.Fatal("expected an error for an invalid timezone")
+	}
+}
+
+func TestBuildDSNTLSParam(t *testing.T) {
+	dsn, err := buildDSN("server1", &config.Server{
+		Hostname:   "db.internal",
+		TLSEnabled: "skip-verify",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("produced an unparseable DSN %q: %v", dsn, err)
+	}
+	if cfg.TLSConfig != "skip-verify" {
+		t.Fatalf("TLSConfig = %v, want skip-verify", cfg.TLSConfig)
+	}
+}
+
+func TestBuildDSNExtraParams(t *testing.T) {
+	dsn, err := buildDSN("server1", &config.Server{
+		Hostname: "db.internal",
+		Charset:  "utf8mb4",
+		Params:   map[string]string{"myparam": "myvalue"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("produced an unparseable DSN %q: %v", dsn, err)
+	}
+	if cfg.Params["charset"] != "utf8mb4" {
+		t.Fatalf("charset param = %v, want utf8mb4", cfg.Params["charset"])
+	}
+	if cfg.Params["myparam"] != "myvalue" {
+		t.Fatalf("myparam = %v, want myvalue", cfg.Params["myparam"])
+	}
+}
@@ -1,20 +1,21 @@
 package beater
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"context"
 	"database/sql"
-	"encoding/hex"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/go-mysql-org/go-mysql/replication"
 
 	"github.com/adibendahan/mysqlbeat/config"
 
@@ -27,36 +28,50 @@ type Mysqlbeat struct {
 	port             string
 	username         string
 	password         string
-	passwordAES      string
 	queries          []string
 	queryTypes       []string
 	deltaWildcard    string
 	deltaKeyWildcard string
 
-	oldValues    common.MapStr
-	oldValuesAge common.MapStr
+	// deltaStates holds the last observed value/age for every __COUNTER /
+	// __GAUGE_RATE column, keyed by deltaKey(server, query, row, column).
+	deltaStates map[string]*deltaState
+	deltaMu     sync.Mutex
+
+	// binlogSyncers holds one BinlogSyncer per server running a
+	// binlog-stream query, so Stop can close them to unblock their
+	// goroutines. binlogMu guards it, since one goroutine per such server
+	// writes to it concurrently. binlogWG is waited on to let those
+	// goroutines finish publishing before the beat exits.
+	binlogSyncers map[string]*replication.BinlogSyncer
+	binlogMu      sync.Mutex
+	binlogWG      sync.WaitGroup
+
+	// dbs holds one pooled *sql.DB per server without password_refresh,
+	// opened at New time and kept for the beat's lifetime (password_refresh
+	// servers open their own every tick). serverBusy flags (one per server) make sure
+	// a tick that's still running when the next one fires is skipped
+	// rather than piling up.
+	dbs        map[string]*sql.DB
+	serverBusy map[string]*int32
+
+	// beatWG is waited on in Stop so a tick still in flight at shutdown
+	// finishes (and stops touching dbs/client) before they're torn down,
+	// the same guarantee binlogWG gives the binlog-stream goroutines.
+	beatWG sync.WaitGroup
 
 	done   chan struct{}
 	config config.Config
 	client publisher.Client
 }
 
-var (
-	commonIV = []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
-)
-
 const (
-	// secret length must be 16, 24 or 32, corresponding to the AES-128, AES-192 or AES-256 algorithms
-	// you should compile your mysqlbeat with a unique secret and hide it (don't leave it in the code after compiled)
-	// you can encrypt your password with github.com/adibendahan/mysqlbeat-password-encrypter just update your secret
-	// (and commonIV if you choose to change it) and compile.
-	secret = "github.com/adibendahan/mysqlbeat"
-
 	// query types values
 	queryTypeSingleRow    = "single-row"
 	queryTypeMultipleRows = "multiple-rows"
 	queryTypeTwoColumns   = "two-columns"
 	queryTypeSlaveDelay   = "show-slave-delay"
+	queryTypeBinlogStream = "binlog-stream"
 
 	// special column names values
 	columnNameSlaveDelay = "Seconds_Behind_Master"
@@ -75,46 +90,61 @@ func New(b *beat.Beat, cfg *common.Config) (beat.Beater, error) {
 	}
 
 	bt := &Mysqlbeat{
-		done:   make(chan struct{}),
-		config: config,
+		done:          make(chan struct{}),
+		config:        config,
+		binlogSyncers: make(map[string]*replication.BinlogSyncer),
+		dbs:           make(map[string]*sql.DB),
+		serverBusy:    make(map[string]*int32),
+		deltaStates:   make(map[string]*deltaState),
 	}
 
-	// init the oldValues and oldValuesAge array
-	bt.oldValues = common.MapStr{"mysqlbeat": "init"}
-	bt.oldValuesAge = common.MapStr{"mysqlbeat": "init"}
-
 	bt.deltaWildcard = bt.config.DeltaWildcard
 	bt.deltaKeyWildcard = bt.config.DeltaKeyWildcard
 
+	if err := registerSecretsProviders(bt.config); err != nil {
+		return nil, err
+	}
+
 	safeQueries := true
 
 	for server, server_params := range bt.config.Servers {
-		// Decrypt passwords for servers
-		if len(server_params.EncryptedPassword) > 0 {
-			aesCipher, err := aes.NewCipher([]byte(secret))
-			if err != nil {
-				return nil, err
-			}
-			cfbDecrypter := cipher.NewCFBDecrypter(aesCipher, commonIV)
-			cipherText, err := hex.DecodeString(server_params.EncryptedPassword)
-			if err != nil {
-				return nil, err
-			}
-			plainTextCopy := make([]byte, len(cipherText))
-			cfbDecrypter.XORKeyStream(plainTextCopy, cipherText)
-			bt.config.Servers[server].Password = string(plainTextCopy)
+		// Resolve the server's password via password_ref, encrypted_password
+		// or the plaintext password field, in that order of precedence.
+		password, err := resolvePassword(server, server_params)
+		if err != nil {
+			return nil, err
 		}
+		bt.config.Servers[server].Password = password
+
+		if server_params.PasswordRefresh && server_params.PasswordRef == "" {
+			return nil, fmt.Errorf("server %v: password_refresh requires password_ref to be set", server)
+		}
+
 		// Validate queries
 		for index, query := range bt.config.Servers[server].Queries {
 
 			strCleanQuery := strings.TrimSpace(strings.ToUpper(query.QueryStr))
 
-			if !strings.HasPrefix(strCleanQuery, "SELECT") && !strings.HasPrefix(strCleanQuery, "SHOW") || strings.ContainsAny(strCleanQuery, ";") {
+			if query.QueryType != queryTypeBinlogStream &&
+				(!strings.HasPrefix(strCleanQuery, "SELECT") && !strings.HasPrefix(strCleanQuery, "SHOW") || strings.ContainsAny(strCleanQuery, ";")) {
 				safeQueries = false
 			}
 
 			logp.Info("Query #%d (type: %s): %s", index+1, query.QueryType, query.QueryStr)
 		}
+
+		// Servers with password_refresh reopen their *sql.DB every tick (see
+		// process_server), since a pooled connection can't pick up a
+		// rotated password. Everyone else gets one pooled *sql.DB that
+		// lives for the beat's lifetime.
+		if !server_params.PasswordRefresh {
+			db, err := openServerDB(server, server_params)
+			if err != nil {
+				return nil, err
+			}
+			bt.dbs[server] = db
+		}
+		bt.serverBusy[server] = new(int32)
 	}
 
 	if !safeQueries {
@@ -129,6 +159,17 @@ func (bt *Mysqlbeat) Run(b *beat.Beat) error {
 	logp.Info("mysqlbeat is running! Hit CTRL-C to stop it.")
 
 	bt.client = b.Publisher.Connect()
+
+	// binlog-stream queries run in their own long-lived goroutines, outside
+	// the ticker loop below.
+	for server, params := range bt.config.Servers {
+		for _, query := range params.Queries {
+			if query.QueryType == queryTypeBinlogStream {
+				bt.startBinlogStream(server, params)
+			}
+		}
+	}
+
 	ticker := time.NewTicker(bt.config.Period)
 	for {
 		select {
@@ -137,142 +178,232 @@ func (bt *Mysqlbeat) Run(b *beat.Beat) error {
 		case <-ticker.C:
 		}
 
-		bt.beat(b)
-		logp.Info("Finished tick")
+		// Run in its own goroutine so a slow tick doesn't hold up the
+		// ticker; beat() skips any server whose previous tick is still
+		// running rather than letting them pile up.
+		bt.beatWG.Add(1)
+		go func() {
+			defer bt.beatWG.Done()
+			bt.beat(b)
+		}()
 	}
 }
 
 func (bt *Mysqlbeat) Stop() {
-	bt.client.Close()
+	// Close done before waiting on beatWG, so Run can no longer add new
+	// beat() goroutines once the current tick (if any) finishes. Waiting
+	// on beatWG first would let a tick firing between the Wait returning
+	// and done being closed start a fresh beat() that touches bt.dbs
+	// concurrently with it being closed below.
 	close(bt.done)
+
+	bt.binlogMu.Lock()
+	for server, syncer := range bt.binlogSyncers {
+		logp.Info("Closing binlog syncer for server %v", server)
+		syncer.Close()
+	}
+	bt.binlogMu.Unlock()
+	bt.binlogWG.Wait()
+	bt.beatWG.Wait()
+
+	for server, db := range bt.dbs {
+		if err := db.Close(); err != nil {
+			logp.Err("Error closing db for server %v: %v", server, err)
+		}
+	}
+
+	bt.client.Close()
 }
 
 ///*** mysqlbeat methods ***///
 
-// beat is a function that iterate over the query array, generate and publish events
+// beat iterates over the configured servers, scraping each one concurrently.
+// A server whose previous tick is still running is skipped with a warning
+// instead of being piled on top of.
 func (bt *Mysqlbeat) beat(b *beat.Beat) {
-	for server, _ := range bt.config.Servers {
-		logp.Info("Starting prcoessing for server %v", server)
-		err := bt.process_server(server)
-		if err != nil {
-			logp.Err("Error occured when processing %v server, got: %v", server, err)
-		} else {
-			logp.Info("Finished for server %v", server)
+	var wg sync.WaitGroup
+
+	for server := range bt.config.Servers {
+		server := server
+		busy := bt.serverBusy[server]
+
+		if !atomic.CompareAndSwapInt32(busy, 0, 1) {
+			logp.Warn("Tick overran period for server %v, skipping this tick", server)
+			continue
 		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer atomic.StoreInt32(busy, 0)
+
+			logp.Info("Starting prcoessing for server %v", server)
+			err := bt.process_server(server)
+			if err != nil {
+				logp.Err("Error occured when processing %v server, got: %v", server, err)
+			} else {
+				logp.Info("Finished for server %v", server)
+			}
+		}()
 	}
+
+	wg.Wait()
 }
 
 func (bt *Mysqlbeat) process_server(server_name string) error {
 	params := bt.config.Servers[server_name]
 
-	if params.Port == "" {
-		params.Port = "3306"
-	}
+	db := bt.dbs[server_name]
 
-	// Build the MySQL connection string
-	connString := fmt.Sprintf("%v:%v@tcp(%v:%v)/", params.Username, params.Password, params.Hostname, params.Port)
+	// Servers with password_refresh can't reuse a pooled *sql.DB across
+	// credential rotations, so re-resolve the password and open a fresh
+	// connection every tick.
+	if params.PasswordRefresh && params.PasswordRef != "" {
+		password, err := resolvePassword(server_name, params)
+		if err != nil {
+			return err
+		}
+		params.Password = password
 
-	db, err := sql.Open("mysql", connString)
-	if err != nil {
-		return err
+		var err2 error
+		db, err2 = openServerDB(server_name, params)
+		if err2 != nil {
+			return err2
+		}
+		defer db.Close()
 	}
-	defer db.Close()
 
-	// Create a two-columns event for later use
-	var twoColumnEvent common.MapStr
 	logp.Info("Prccessing %v queries for %v server", len(params.Queries), server_name)
-LoopQueries:
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
 	for index, query := range params.Queries {
-		// Log the query run time and run the query
-		dtNow := time.Now()
-		rows, err := db.Query(query.QueryStr)
-		if err != nil {
-			return err
+		// binlog-stream queries run in their own goroutine (see
+		// startBinlogStream), not on the tick-based ticker.
+		if query.QueryType == queryTypeBinlogStream {
+			continue
 		}
 
-		// Populate columns array
-		columns, err := rows.Columns()
-		if err != nil {
-			return err
-		}
+		index, query := index, query
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		// Populate the two-columns event
-		if query.QueryType == queryTypeTwoColumns {
-			twoColumnEvent = common.MapStr{
-				"@timestamp": common.Time(dtNow),
-				"type":       queryTypeTwoColumns,
-				"hostname":   server_name,
+			if err := bt.processQuery(db, server_name, index, query); err != nil {
+				logp.Err("Query #%v error on server %v: %v", index+1, server_name, err)
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
 			}
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+// processQuery runs a single query with its own timeout (Query.Timeout,
+// defaulting to the beat's Period) and publishes the resulting event(s).
+func (bt *Mysqlbeat) processQuery(db *sql.DB, server_name string, index int, query config.Query) error {
+	timeout := query.Timeout
+	if timeout == 0 {
+		timeout = bt.config.Period
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Log the query run time and run the query
+	dtNow := time.Now()
+	rows, err := db.QueryContext(ctx, query.QueryStr)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Populate columns array
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	// Create a two-columns event for later use
+	var twoColumnEvent common.MapStr
+	if query.QueryType == queryTypeTwoColumns {
+		twoColumnEvent = common.MapStr{
+			"@timestamp": common.Time(dtNow),
+			"type":       queryTypeTwoColumns,
+			"hostname":   server_name,
 		}
+	}
 
-	LoopRows:
-		for rows.Next() {
+LoopRows:
+	for rows.Next() {
 
-			switch query.QueryType {
-			case queryTypeSingleRow, queryTypeSlaveDelay:
-				// Generate an event from the current row
-				event, err := bt.generateEventFromRow(rows, columns, query.QueryType, dtNow)
+		switch query.QueryType {
+		case queryTypeSingleRow, queryTypeSlaveDelay:
+			// Generate an event from the current row
+			event, err := bt.generateEventFromRow(rows, columns, query.QueryType, dtNow, server_name, index)
 
-				if err != nil {
-					logp.Err("Query #%v error generating event from rows: %v", index+1, err)
-				} else if event != nil {
-					event["hostname"] = server_name
-					bt.client.PublishEvent(event)
-					logp.Info("%v event sent", query.QueryType)
-				}
-				// breaking after the first row
-				break LoopRows
+			if err != nil {
+				logp.Err("Query #%v error generating event from rows: %v", index+1, err)
+			} else if event != nil {
+				event["hostname"] = server_name
+				bt.client.PublishEvent(event)
+				logp.Info("%v event sent", query.QueryType)
+			}
+			// breaking after the first row
+			break LoopRows
 
-			case queryTypeMultipleRows:
-				// Generate an event from the current row
-				event, err := bt.generateEventFromRow(rows, columns, query.QueryType, dtNow)
-
-				if err != nil {
-					logp.Err("Query #%v error generating event from rows: %v", index+1, err)
-					break LoopRows
-				} else if event != nil {
-					event["hostname"] = server_name
-					bt.client.PublishEvent(event)
-					logp.Info("%v event sent", query.QueryType)
-				}
+		case queryTypeMultipleRows:
+			// Generate an event from the current row
+			event, err := bt.generateEventFromRow(rows, columns, query.QueryType, dtNow, server_name, index)
 
-				// Move to the next row
-				continue LoopRows
+			if err != nil {
+				logp.Err("Query #%v error generating event from rows: %v", index+1, err)
+				break LoopRows
+			} else if event != nil {
+				event["hostname"] = server_name
+				bt.client.PublishEvent(event)
+				logp.Info("%v event sent", query.QueryType)
+			}
 
-			case queryTypeTwoColumns:
-				// append current row to the two-columns event
-				err := bt.appendRowToEvent(twoColumnEvent, rows, columns, dtNow)
+			// Move to the next row
+			continue LoopRows
 
-				if err != nil {
-					logp.Err("Query #%v error appending two-columns event: %v", index+1, err)
-					break LoopRows
-				}
+		case queryTypeTwoColumns:
+			// append current row to the two-columns event
+			err := bt.appendRowToEvent(twoColumnEvent, rows, columns, dtNow, server_name, index)
 
-				// Move to the next row
-				continue LoopRows
+			if err != nil {
+				logp.Err("Query #%v error appending two-columns event: %v", index+1, err)
+				break LoopRows
 			}
-		}
 
-		// If the two-columns event has data, publish it
-		if query.QueryType == queryTypeTwoColumns && len(twoColumnEvent) > 3 {
-			bt.client.PublishEvent(twoColumnEvent)
-			logp.Info("%v event sent", queryTypeTwoColumns)
-			twoColumnEvent = nil
+			// Move to the next row
+			continue LoopRows
 		}
+	}
 
-		rows.Close()
-		if err = rows.Err(); err != nil {
-			logp.Err("Query #%v error closing rows: %v", index+1, err)
-			continue LoopQueries
-		}
+	// If the two-columns event has data, publish it
+	if query.QueryType == queryTypeTwoColumns && len(twoColumnEvent) > 3 {
+		bt.client.PublishEvent(twoColumnEvent)
+		logp.Info("%v event sent", queryTypeTwoColumns)
+		twoColumnEvent = nil
 	}
 
-	// Great success!
-	return nil
+	return rows.Err()
 }
 
-// appendRowToEvent appends the two-column event the current row data
-func (bt *Mysqlbeat) appendRowToEvent(event common.MapStr, row *sql.Rows, columns []string, rowAge time.Time) error {
+// appendRowToEvent appends the two-column event the current row data. Each
+// row is its own metric (the column value is the name), so the row key used
+// for delta state is just the metric name itself.
+func (bt *Mysqlbeat) appendRowToEvent(event common.MapStr, row *sql.Rows, columns []string, rowAge time.Time, serverName string, queryIndex int) error {
 
 	// Make a slice for the values
 	values := make([]sql.RawBytes, len(columns))
@@ -293,7 +424,6 @@ func (bt *Mysqlbeat) appendRowToEvent(event common.MapStr, row *sql.Rows, column
 	strColName := string(values[0])
 	strColValue := string(values[1])
 	strColType := columnTypeString
-	strEventColName := strings.Replace(strColName, bt.deltaWildcard, "_PERSECOND", 1)
 
 	// Try to parse the value to an int64
 	nColValue, err := strconv.ParseInt(strColValue, 0, 64)
@@ -310,78 +440,55 @@ func (bt *Mysqlbeat) appendRowToEvent(event common.MapStr, row *sql.Rows, column
 		}
 	}
 
-	// If the column name ends with the deltaWildcard
-	if strings.HasSuffix(strColName, bt.deltaWildcard) {
-		var exists bool
-		_, exists = bt.oldValues[strColName]
+	if metric, le, ok := histogramColumn(strColName); ok {
+		if strColType != columnTypeInt {
+			return fmt.Errorf("column %v: histogram bucket count %q is not an integer", strColName, strColValue)
+		}
+		appendHistogramBucket(event, metric, le, nColValue)
+		return nil
+	}
 
-		// If an older value doesn't exist
-		if !exists {
-			// Save the current value in the oldValues array
-			bt.oldValuesAge[strColName] = rowAge
+	kind, baseName := bt.classifyColumn(strColName)
+	key := deltaKey(serverName, queryIndex, "", strColName)
 
-			if strColType == columnTypeString {
-				bt.oldValues[strColName] = strColValue
-			} else if strColType == columnTypeInt {
-				bt.oldValues[strColName] = nColValue
-			} else if strColType == columnTypeFloat {
-				bt.oldValues[strColName] = fColValue
+	switch kind {
+	case deltaKindCounter:
+		if strColType == columnTypeInt {
+			if rate, resetTotal, hadPrevious := bt.resolveCounter(key, nColValue, rowAge); hadPrevious {
+				event[baseName+"_PERSECOND"] = rate
+				if resetTotal > 0 {
+					event[baseName+"_reset_total"] = resetTotal
+				}
 			}
-		} else {
-			// If found the old value's age
-			if dtOldAge, ok := bt.oldValuesAge[strColName].(time.Time); ok {
-				delta := rowAge.Sub(dtOldAge)
-
-				if strColType == columnTypeInt {
-					var calcVal int64
-
-					// Get old value
-					oldVal, _ := bt.oldValues[strColName].(int64)
-					if nColValue > oldVal {
-						// Calculate the delta
-						devResult := float64((nColValue - oldVal)) / float64(delta.Seconds())
-						// Round the calculated result back to an int64
-						calcVal = roundF2I(devResult, .5)
-					} else {
-						calcVal = 0
-					}
-
-					// Add the delta value to the event
-					event[strEventColName] = calcVal
-
-					// Save current values as old values
-					bt.oldValues[strColName] = nColValue
-					bt.oldValuesAge[strColName] = rowAge
-				} else if strColType == columnTypeFloat {
-					var calcVal float64
-
-					// Get old value
-					oldVal, _ := bt.oldValues[strColName].(float64)
-					if fColValue > oldVal {
-						// Calculate the delta
-						calcVal = (fColValue - oldVal) / float64(delta.Seconds())
-					} else {
-						calcVal = 0
-					}
-
-					// Add the delta value to the event
-					event[strEventColName] = calcVal
-
-					// Save current values as old values
-					bt.oldValues[strColName] = fColValue
-					bt.oldValuesAge[strColName] = rowAge
-				} else {
-					event[strEventColName] = strColValue
+		} else if strColType == columnTypeFloat {
+			if rate, resetTotal, hadPrevious := bt.resolveCounterFloat(key, fColValue, rowAge); hadPrevious {
+				event[baseName+"_PERSECOND"] = rate
+				if resetTotal > 0 {
+					event[baseName+"_reset_total"] = resetTotal
 				}
 			}
+		} else {
+			event[baseName] = strColValue
 		}
-	} else { // Not a delta column, add the value to the event as is
+
+	case deltaKindGaugeRate:
+		var val float64
+		if strColType == columnTypeInt {
+			val = float64(nColValue)
+		} else {
+			val = fColValue
+		}
+		if rate, hadPrevious := bt.resolveGaugeRate(key, val, rowAge); hadPrevious {
+			event[baseName+"_PERSECOND"] = rate
+		}
+
+	default: // Not a delta column, add the value to the event as is
 		if strColType == columnTypeString {
-			event[strEventColName] = strColValue
+			event[strColName] = strColValue
 		} else if strColType == columnTypeInt {
-			event[strEventColName] = nColValue
+			event[strColName] = nColValue
 		} else if strColType == columnTypeFloat {
-			event[strEventColName] = fColValue
+			event[strColName] = fColValue
 		}
 	}
 
@@ -390,7 +497,7 @@ func (bt *Mysqlbeat) appendRowToEvent(event common.MapStr, row *sql.Rows, column
 }
 
 // generateEventFromRow creates a new event from the row data and returns it
-func (bt *Mysqlbeat) generateEventFromRow(row *sql.Rows, columns []string, queryType string, rowAge time.Time) (common.MapStr, error) {
+func (bt *Mysqlbeat) generateEventFromRow(row *sql.Rows, columns []string, queryType string, rowAge time.Time, serverName string, queryIndex int) (common.MapStr, error) {
 
 	// Make a slice for the values
 	values := make([]sql.RawBytes, len(columns))
@@ -425,16 +532,6 @@ func (bt *Mysqlbeat) generateEventFromRow(row *sql.Rows, columns []string, query
 			continue
 		}
 
-		// Set the event column name to the original column name (as default)
-		strEventColName := strColName
-
-		// Remove unneeded suffix, add _PERSECOND to calculated columns
-		if strings.HasSuffix(strColName, bt.deltaKeyWildcard) {
-			strEventColName = strings.Replace(strColName, bt.deltaKeyWildcard, "", 1)
-		} else if strings.HasSuffix(strColName, bt.deltaWildcard) {
-			strEventColName = strings.Replace(strColName, bt.deltaWildcard, "_PERSECOND", 1)
-		}
-
 		// Try to parse the value to an int64
 		nColValue, err := strconv.ParseInt(strColValue, 0, 64)
 		if err == nil {
@@ -450,95 +547,78 @@ func (bt *Mysqlbeat) generateEventFromRow(row *sql.Rows, columns []string, query
 			}
 		}
 
-		// If the column name ends with the deltaWildcard
-		if (queryType == queryTypeSingleRow || queryType == queryTypeMultipleRows) && strings.HasSuffix(strColName, bt.deltaWildcard) {
-
-			var strKey string
-
-			// Get unique row key, if it's a single row - use the column name
-			if queryType == queryTypeSingleRow {
-				strKey = strColName
-			} else if queryType == queryTypeMultipleRows {
-
-				// If the query has multiple rows, a unique row key must be defind using the delta key wildcard and the column name
-				strKey, err = getKeyFromRow(bt, values, columns)
-				if err != nil {
-					return nil, err
-				}
+		if strings.HasSuffix(strColName, bt.deltaKeyWildcard) {
+			// Delta key columns identify the row for multiple-rows delta
+			// state; they're not metrics themselves, just re-added under
+			// their bare name.
+			event[strings.Replace(strColName, bt.deltaKeyWildcard, "", 1)] = strColValue
+			continue
+		}
 
-				strKey += strColName
+		if metric, le, ok := histogramColumn(strColName); ok {
+			if strColType != columnTypeInt {
+				return nil, fmt.Errorf("column %v: histogram bucket count %q is not an integer", strColName, strColValue)
 			}
+			appendHistogramBucket(event, metric, le, nColValue)
+			continue
+		}
 
-			var exists bool
-			_, exists = bt.oldValues[strKey]
+		kind, baseName := bt.classifyColumn(strColName)
 
-			// If an older value doesn't exist
-			if !exists {
-				// Save the current value in the oldValues array
-				bt.oldValuesAge[strKey] = rowAge
+		if kind == deltaKindNone || (queryType != queryTypeSingleRow && queryType != queryTypeMultipleRows) {
+			// Not a delta column (or a delta suffix on a query type that
+			// doesn't support one), add the value to the event as is.
+			if strColType == columnTypeString {
+				event[strColName] = strColValue
+			} else if strColType == columnTypeInt {
+				event[strColName] = nColValue
+			} else if strColType == columnTypeFloat {
+				event[strColName] = fColValue
+			}
+			continue
+		}
 
-				if strColType == columnTypeString {
-					bt.oldValues[strKey] = strColValue
-				} else if strColType == columnTypeInt {
-					bt.oldValues[strKey] = nColValue
-				} else if strColType == columnTypeFloat {
-					bt.oldValues[strKey] = fColValue
+		// Get unique row key: for a single row, the column name is enough;
+		// for multiple rows, it must be combined with the row's delta key
+		// column(s).
+		rowKey := ""
+		if queryType == queryTypeMultipleRows {
+			rowKey, err = getKeyFromRow(bt, values, columns)
+			if err != nil {
+				return nil, err
+			}
+		}
+		key := deltaKey(serverName, queryIndex, rowKey, strColName)
+
+		switch kind {
+		case deltaKindCounter:
+			if strColType == columnTypeInt {
+				if rate, resetTotal, hadPrevious := bt.resolveCounter(key, nColValue, rowAge); hadPrevious {
+					event[baseName+"_PERSECOND"] = rate
+					if resetTotal > 0 {
+						event[baseName+"_reset_total"] = resetTotal
+					}
 				}
-			} else {
-				// If found the old value's age
-				if dtOldAge, ok := bt.oldValuesAge[strKey].(time.Time); ok {
-					delta := rowAge.Sub(dtOldAge)
-
-					if strColType == columnTypeInt {
-						var calcVal int64
-
-						// Get old value
-						oldVal, _ := bt.oldValues[strKey].(int64)
-
-						if nColValue > oldVal {
-							// Calculate the delta
-							devResult := float64((nColValue - oldVal)) / float64(delta.Seconds())
-							// Round the calculated result back to an int64
-							calcVal = roundF2I(devResult, .5)
-						} else {
-							calcVal = 0
-						}
-
-						// Add the delta value to the event
-						event[strEventColName] = calcVal
-
-						// Save current values as old values
-						bt.oldValues[strKey] = nColValue
-						bt.oldValuesAge[strKey] = rowAge
-					} else if strColType == columnTypeFloat {
-						var calcVal float64
-						oldVal, _ := bt.oldValues[strKey].(float64)
-
-						if fColValue > oldVal {
-							// Calculate the delta
-							calcVal = (fColValue - oldVal) / float64(delta.Seconds())
-						} else {
-							calcVal = 0
-						}
-
-						// Add the delta value to the event
-						event[strEventColName] = calcVal
-
-						// Save current values as old values
-						bt.oldValues[strKey] = fColValue
-						bt.oldValuesAge[strKey] = rowAge
-					} else {
-						event[strEventColName] = strColValue
+			} else if strColType == columnTypeFloat {
+				if rate, resetTotal, hadPrevious := bt.resolveCounterFloat(key, fColValue, rowAge); hadPrevious {
+					event[baseName+"_PERSECOND"] = rate
+					if resetTotal > 0 {
+						event[baseName+"_reset_total"] = resetTotal
 					}
 				}
+			} else {
+				event[baseName] = strColValue
 			}
-		} else { // Not a delta column, add the value to the event as is
-			if strColType == columnTypeString {
-				event[strEventColName] = strColValue
-			} else if strColType == columnTypeInt {
-				event[strEventColName] = nColValue
-			} else if strColType == columnTypeFloat {
-				event[strEventColName] = fColValue
+
+		case deltaKindGaugeRate:
+			var val float64
+			if strColType == columnTypeInt {
+				val = float64(nColValue)
+			} else {
+				val = fColValue
+			}
+			if rate, hadPrevious := bt.resolveGaugeRate(key, val, rowAge); hadPrevious {
+				event[baseName+"_PERSECOND"] = rate
 			}
 		}
 	}
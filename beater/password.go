@@ -0,0 +1,62 @@
+package beater
+
+import (
+	"fmt"
+
+	"github.com/adibendahan/mysqlbeat/config"
+	"github.com/adibendahan/mysqlbeat/secrets"
+)
+
+// registerSecretsProviders wires up the secrets providers that need
+// configuration (env/file register themselves on import). aes only becomes
+// available when a keyfile is configured, and vault only when credentials
+// are configured, so using password_ref with either scheme unconfigured
+// fails fast with a clear error rather than silently falling back.
+func registerSecretsProviders(cfg config.Config) error {
+	if cfg.SecretsAESKeyFile != "" {
+		provider, err := secrets.NewAESProvider(cfg.SecretsAESKeyFile)
+		if err != nil {
+			return err
+		}
+		secrets.Register("aes", provider)
+	}
+
+	if cfg.VaultToken != "" || (cfg.VaultRoleID != "" && cfg.VaultSecretID != "") {
+		provider, err := secrets.NewVaultProvider(secrets.VaultOptions{
+			Address:  cfg.VaultAddress,
+			Token:    cfg.VaultToken,
+			RoleID:   cfg.VaultRoleID,
+			SecretID: cfg.VaultSecretID,
+		})
+		if err != nil {
+			return err
+		}
+		secrets.Register("vault", provider)
+	}
+
+	return nil
+}
+
+// resolvePassword returns the password to use for a server, preferring
+// password_ref, then encrypted_password (resolved via the "aes" provider),
+// then the plaintext password field.
+func resolvePassword(serverName string, params *config.Server) (string, error) {
+	switch {
+	case params.PasswordRef != "":
+		password, err := secrets.Resolve(params.PasswordRef)
+		if err != nil {
+			return "", fmt.Errorf("error resolving password_ref for server %v: %v", serverName, err)
+		}
+		return password, nil
+
+	case params.EncryptedPassword != "":
+		password, err := secrets.Resolve("aes:" + params.EncryptedPassword)
+		if err != nil {
+			return "", fmt.Errorf("error resolving encrypted_password for server %v: %v", serverName, err)
+		}
+		return password, nil
+
+	default:
+		return params.Password, nil
+	}
+}
@@ -0,0 +1,48 @@
+package beater
+
+import (
+	"os"
+	"testing"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+func TestResolvePasswordPlaintext(t *testing.T) {
+	got, err := resolvePassword("server1", &config.Server{Password: "plaintext-pw"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "plaintext-pw" {
+		t.Fatalf("got %q, want %q", got, "plaintext-pw")
+	}
+}
+
+func TestResolvePasswordRef(t *testing.T) {
+	os.Setenv("MYSQLBEAT_TEST_PASSWORD_REF", "from-env")
+	defer os.Unsetenv("MYSQLBEAT_TEST_PASSWORD_REF")
+
+	got, err := resolvePassword("server1", &config.Server{
+		PasswordRef: "env:MYSQLBEAT_TEST_PASSWORD_REF",
+		// password_ref takes precedence even when a plaintext password is
+		// also set.
+		Password: "should-be-ignored",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "from-env" {
+		t.Fatalf("got %q, want %q", got, "from-env")
+	}
+}
+
+func TestResolvePasswordRefError(t *testing.T) {
+	if _, err := resolvePassword("server1", &config.Server{PasswordRef: "env:MYSQLBEAT_TEST_PASSWORD_REF_UNSET"}); err == nil {
+		t.Fatal("expected an error when password_ref points at an unset variable")
+	}
+}
+
+func TestRegisterSecretsProvidersNoop(t *testing.T) {
+	if err := registerSecretsProviders(config.Config{}); err != nil {
+		t.Fatalf("expected no error when no secrets backend is configured, got: %v", err)
+	}
+}
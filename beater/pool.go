@@ -0,0 +1,42 @@
+package beater
+
+import (
+	"database/sql"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+const (
+	defaultMaxOpenConns = 10
+)
+
+// openServerDB opens and configures the *sql.DB for a server. New opens it
+// once and keeps it for the beat's lifetime; only servers with
+// password_refresh reopen it every tick (see process_server).
+func openServerDB(serverName string, params *config.Server) (*sql.DB, error) {
+	dsn, err := buildDSN(serverName, params)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	maxOpenConns := params.MaxOpenConns
+	if maxOpenConns == 0 {
+		maxOpenConns = defaultMaxOpenConns
+	}
+
+	maxIdleConns := params.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = maxOpenConns
+	}
+
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(params.ConnMaxLifetime)
+
+	return db, nil
+}
@@ -0,0 +1,74 @@
+package beater
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+// builtin TLS mode names understood directly by the go-sql-driver, see
+// https://github.com/go-sql-driver/mysql#tls
+var builtinTLSModes = map[string]bool{
+	"true":        true,
+	"skip-verify": true,
+	"preferred":   true,
+}
+
+// tlsParamFor returns the value to use for the DSN's "tls" parameter for the
+// given server, registering a custom *tls.Config under a unique name when
+// the server supplies its own CA/cert/key. An empty string means TLS is not
+// requested for this server.
+func tlsParamFor(serverName string, params *config.Server) (string, error) {
+	if params.TLSEnabled == "" || params.TLSEnabled == "false" {
+		return "", nil
+	}
+
+	// Built-in driver modes are used as-is when no custom certs are given
+	// and there's no server name override to honor: the driver's builtin
+	// modes always verify against the DSN host, so tls_server_name (e.g.
+	// connecting through a proxy/SNI router where the DSN host differs
+	// from the cert's name) requires a custom *tls.Config to take effect.
+	if builtinTLSModes[params.TLSEnabled] && params.TLSCA == "" && params.TLSCert == "" && params.TLSKey == "" && params.TLSServerName == "" {
+		return params.TLSEnabled, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         params.TLSServerName,
+		InsecureSkipVerify: params.TLSInsecureSkipVerify,
+	}
+
+	if params.TLSCA != "" {
+		pem, err := ioutil.ReadFile(params.TLSCA)
+		if err != nil {
+			return "", fmt.Errorf("error reading tls_ca for server %v: %v", serverName, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return "", fmt.Errorf("error parsing tls_ca for server %v", serverName)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if params.TLSCert != "" || params.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(params.TLSCert, params.TLSKey)
+		if err != nil {
+			return "", fmt.Errorf("error loading tls_cert/tls_key for server %v: %v", serverName, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// Register under a name unique to this server so servers with different
+	// certs don't clash in the driver's global registry.
+	tlsConfigName := "mysqlbeat-" + serverName
+	if err := mysql.RegisterTLSConfig(tlsConfigName, tlsConfig); err != nil {
+		return "", fmt.Errorf("error registering tls config for server %v: %v", serverName, err)
+	}
+
+	return tlsConfigName, nil
+}
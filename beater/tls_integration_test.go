@@ -0,0 +1,69 @@
+// +build integration
+
+package beater
+
+import (
+	"testing"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+// TestTLSIntegrationConnect dials the MySQL server brought up by
+// docker-compose.yml (configured with --require-secure-transport=ON and the
+// cert pair in testdata/tls) and runs a trivial query over the connection,
+// once with the custom cert pair and once with the "skip-verify" builtin
+// mode. Run with:
+//
+//	docker-compose up -d
+//	go test -tags integration ./beater/...
+func TestTLSIntegrationConnect(t *testing.T) {
+	cases := []struct {
+		name   string
+		params *config.Server
+	}{
+		{
+			name: "custom CA",
+			params: &config.Server{
+				Hostname:   "127.0.0.1",
+				Port:       "3306",
+				Username:   "root",
+				Password:   "mysqlbeat-test",
+				Database:   "mysqlbeat_test",
+				TLSEnabled: "custom",
+				TLSCA:      "testdata/tls/ca-cert.pem",
+				TLSCert:    "testdata/tls/client-cert.pem",
+				TLSKey:     "testdata/tls/client-key.pem",
+			},
+		},
+		{
+			name: "skip-verify",
+			params: &config.Server{
+				Hostname:   "127.0.0.1",
+				Port:       "3306",
+				Username:   "root",
+				Password:   "mysqlbeat-test",
+				Database:   "mysqlbeat_test",
+				TLSEnabled: "skip-verify",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db, err := openServerDB("integration-"+c.name, c.params)
+			if err != nil {
+				t.Fatalf("openServerDB: %v", err)
+			}
+			defer db.Close()
+
+			var variable, value string
+			row := db.QueryRow("SHOW STATUS LIKE 'Ssl_cipher'")
+			if err := row.Scan(&variable, &value); err != nil {
+				t.Fatalf("querying Ssl_cipher status: %v", err)
+			}
+			if value == "" {
+				t.Fatal("expected a non-empty Ssl_cipher, connection was not encrypted")
+			}
+		})
+	}
+}
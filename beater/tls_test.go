@@ -0,0 +1,98 @@
+package beater
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adibendahan/mysqlbeat/config"
+)
+
+func TestTLSParamForDisabled(t *testing.T) {
+	param, err := tlsParamFor("server1", &config.Server{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if param != "" {
+		t.Fatalf("expected empty tls param when tls_enabled is unset, got %q", param)
+	}
+}
+
+func TestTLSParamForExplicitFalse(t *testing.T) {
+	// "false" mirrors the driver's own tls= vocabulary for "no TLS" and
+	// must not fall through to the custom-config branch, which would
+	// silently turn encryption on against the operator's intent.
+	param, err := tlsParamFor("server1", &config.Server{TLSEnabled: "false"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if param != "" {
+		t.Fatalf("expected tls_enabled: false to disable TLS, got %q", param)
+	}
+}
+
+func TestTLSParamForBuiltinModes(t *testing.T) {
+	for _, mode := range []string{"true", "skip-verify", "preferred"} {
+		param, err := tlsParamFor("server1", &config.Server{TLSEnabled: mode})
+		if err != nil {
+			t.Fatalf("mode %v: unexpected error: %v", mode, err)
+		}
+		if param != mode {
+			t.Fatalf("mode %v: expected builtin mode to pass through as-is, got %q", mode, param)
+		}
+	}
+}
+
+func TestTLSParamForServerNameForcesCustomConfig(t *testing.T) {
+	// tls_server_name can't be honored by a builtin mode, which always
+	// verifies against the DSN host, so it must force registration of a
+	// custom *tls.Config even with no CA/cert/key supplied.
+	param, err := tlsParamFor("server-name-override", &config.Server{
+		TLSEnabled:    "true",
+		TLSServerName: "proxy.internal",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if param == "true" {
+		t.Fatalf("expected tls_server_name to force a registered custom config, got builtin mode %q", param)
+	}
+	if !strings.HasPrefix(param, "mysqlbeat-server-name-override") {
+		t.Fatalf("expected a config name scoped to the server, got %q", param)
+	}
+}
+
+func TestTLSParamForCustomCerts(t *testing.T) {
+	param, err := tlsParamFor("server-custom", &config.Server{
+		TLSEnabled: "custom",
+		TLSCA:      "testdata/tls/ca-cert.pem",
+		TLSCert:    "testdata/tls/client-cert.pem",
+		TLSKey:     "testdata/tls/client-key.pem",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if param != "mysqlbeat-server-custom" {
+		t.Fatalf("expected the registered config name, got %q", param)
+	}
+}
+
+func TestTLSParamForMissingCAFile(t *testing.T) {
+	_, err := tlsParamFor("server-bad-ca", &config.Server{
+		TLSEnabled: "custom",
+		TLSCA:      "testdata/tls/does-not-exist.pem",
+	})
+	if err == nil {
+		t.Fatal("expected an error for a missing tls_ca file")
+	}
+}
+
+func TestTLSParamForBadKeyPair(t *testing.T) {
+	_, err := tlsParamFor("server-bad-keypair", &config.Server{
+		TLSEnabled: "custom",
+		TLSCert:    "testdata/tls/client-cert.pem",
+		TLSKey:     "testdata/tls/server-key.pem",
+	})
+	if err == nil {
+		t.Fatal("expected an error for a cert/key that don't match")
+	}
+}
@@ -10,20 +10,84 @@ type Config struct {
 	Servers          map[string]*Server `config:servers`
 	DeltaWildcard    string             `config:"deltawildcard"`
 	DeltaKeyWildcard string             `config:"deltakeywildcard"`
+
+	// SecretsAESKeyFile, when set, enables the "aes:" secrets provider
+	// (and encrypted_password) using the key/IV stored in that file.
+	SecretsAESKeyFile string `config:"secrets_aes_keyfile"`
+
+	// Vault* configure the "vault:" secrets provider. A token takes
+	// precedence over VaultRoleID/VaultSecretID (AppRole login).
+	VaultAddress  string `config:"vault_address"`
+	VaultToken    string `config:"vault_token"`
+	VaultRoleID   string `config:"vault_role_id"`
+	VaultSecretID string `config:"vault_secret_id"`
 }
 
 type Server struct {
-	Hostname          string  `config:"hostname"`
-	Port              string  `config:"port"`
-	Username          string  `config:"username"`
-	Password          string  `config:"password"`
-	EncryptedPassword string  `config:"encrypted_password"`
-	Queries           []Query `config:"queries"`
+	Hostname          string `config:"hostname"`
+	Port              string `config:"port"`
+	Username          string `config:"username"`
+	Password          string `config:"password"`
+	EncryptedPassword string `config:"encrypted_password"`
+	// PasswordRef resolves the password through the secrets package, e.g.
+	// "vault:secret/data/mysql#password" or "env:MYSQL_PASSWORD". It takes
+	// precedence over Password/EncryptedPassword.
+	PasswordRef string `config:"password_ref"`
+	// PasswordRefresh re-resolves PasswordRef on every tick instead of once
+	// at startup, for short-lived credentials (e.g. Vault dynamic secrets).
+	PasswordRefresh bool    `config:"password_refresh"`
+	Queries         []Query `config:"queries"`
+
+	// TLSEnabled turns on an encrypted connection to the server. It accepts
+	// one of the go-sql-driver built-in modes ("true", "skip-verify",
+	// "preferred") or "custom" to build a *tls.Config from the TLSCA/TLSCert/
+	// TLSKey fields below.
+	TLSEnabled            string `config:"tls_enabled"`
+	TLSCA                 string `config:"tls_ca"`
+	TLSCert               string `config:"tls_cert"`
+	TLSKey                string `config:"tls_key"`
+	TLSServerName         string `config:"tls_server_name"`
+	TLSInsecureSkipVerify bool   `config:"tls_insecure_skip_verify"`
+
+	// Connection string fields, assembled into a mysql.Config rather than a
+	// hand-built DSN so special characters, IPv6 hosts and unix sockets all
+	// work correctly.
+	Protocol             string            `config:"protocol"` // "tcp" (default) or "unix"
+	SocketPath           string            `config:"socket_path"`
+	Database             string            `config:"database"`
+	Charset              string            `config:"charset"`
+	Collation            string            `config:"collation"`
+	Timezone             string            `config:"timezone"`
+	ConnectTimeout       time.Duration     `config:"connect_timeout"`
+	ReadTimeout          time.Duration     `config:"read_timeout"`
+	WriteTimeout         time.Duration     `config:"write_timeout"`
+	Params               map[string]string `config:"params"`
+	AllowNativePasswords bool              `config:"allow_native_passwords"`
+
+	// Connection pool knobs. The server's *sql.DB is opened once and kept
+	// for the beat's lifetime, so these behave like any long-running
+	// client's pool settings rather than per-tick options. With
+	// PasswordRefresh the *sql.DB is reopened every tick instead.
+	MaxOpenConns    int           `config:"max_open_conns"`
+	MaxIdleConns    int           `config:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime"`
+
+	// Binlog replication fields, used by queries of type "binlog-stream".
+	ServerID       uint32 `config:"server_id"`
+	BinlogFile     string `config:"binlog_file"`
+	BinlogPos      uint32 `config:"binlog_pos"`
+	GTIDSet        string `config:"gtid_set"`
+	IncludeTables  string `config:"include_tables"` // regex
+	ExcludeTables  string `config:"exclude_tables"` // regex
+	CheckpointFile string `config:"checkpoint_file"`
 }
 
 type Query struct {
 	QueryStr  string `config:"query"`
 	QueryType string `config:"type"`
+	// Timeout bounds how long this query may run. Defaults to the beat's
+	// Period when unset.
+	Timeout time.Duration `config:"timeout"`
 }
 
 var DefaultConfig = Config{
@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// AESProvider decrypts a hex-encoded AES-CFB ciphertext, e.g.
+// "aes:8f3a...". It's the same scheme mysqlbeat always used for
+// encrypted_password, except the key and IV are now loaded from a keyfile
+// instead of being a compile-time constant, so rotating them doesn't
+// require a rebuild.
+type AESProvider struct {
+	key []byte
+	iv  []byte
+}
+
+// NewAESProvider loads the AES key and IV from keyFile. The file must
+// contain two lines: the hex-encoded key (16, 24 or 32 bytes once decoded,
+// for AES-128/192/256), then the hex-encoded 16-byte IV.
+func NewAESProvider(keyFile string) (*AESProvider, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: error reading aes keyfile: %v", err)
+	}
+
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 2)
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("secrets: aes keyfile must contain a key line and an iv line")
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, fmt.Errorf("secrets: error decoding aes key: %v", err)
+	}
+
+	iv, err := hex.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return nil, fmt.Errorf("secrets: error decoding aes iv: %v", err)
+	}
+
+	return &AESProvider{key: key, iv: iv}, nil
+}
+
+func (p *AESProvider) Resolve(ref string) (string, error) {
+	cipherText, err := hex.DecodeString(ref)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(p.key)
+	if err != nil {
+		return "", err
+	}
+
+	plainText := make([]byte, len(cipherText))
+	cipher.NewCFBDecrypter(block, p.iv).XORKeyStream(plainText, cipherText)
+
+	return string(plainText), nil
+}
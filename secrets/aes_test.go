@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeAESKeyFile(t *testing.T, dir string, key, iv []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "aes.key")
+	contents := hex.EncodeToString(key) + "\n" + hex.EncodeToString(iv) + "\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestAESProviderResolveRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqlbeat-secrets-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef") // AES-128
+	iv := []byte("abcdef0123456789")
+
+	keyFile := writeAESKeyFile(t, dir, key, iv)
+	provider, err := NewAESProvider(keyFile)
+	if err != nil {
+		t.Fatalf("NewAESProvider: %v", err)
+	}
+
+	plainText := []byte("hunter2")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("aes.NewCipher: %v", err)
+	}
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCFBEncrypter(block, iv).XORKeyStream(cipherText, plainText)
+
+	got, err := provider.Resolve(hex.EncodeToString(cipherText))
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got != "hunter2" {
+		t.Fatalf("got %q, want %q", got, "hunter2")
+	}
+}
+
+func TestNewAESProviderMissingFile(t *testing.T) {
+	if _, err := NewAESProvider("/no/such/path/mysqlbeat-test"); err == nil {
+		t.Fatal("expected an error for a missing keyfile")
+	}
+}
+
+func TestNewAESProviderMalformedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqlbeat-secrets-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "aes.key")
+	if err := ioutil.WriteFile(path, []byte("only-one-line\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := NewAESProvider(path); err == nil {
+		t.Fatal("expected an error for a keyfile missing the iv line")
+	}
+}
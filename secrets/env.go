@@ -0,0 +1,22 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+)
+
+// EnvProvider resolves a reference to the value of an environment variable,
+// e.g. "env:MYSQL_PASSWORD".
+type EnvProvider struct{}
+
+func (EnvProvider) Resolve(ref string) (string, error) {
+	val, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("secrets: environment variable %q is not set", ref)
+	}
+	return val, nil
+}
+
+func init() {
+	Register("env", EnvProvider{})
+}
@@ -0,0 +1,25 @@
+package secrets
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnvProviderResolve(t *testing.T) {
+	os.Setenv("MYSQLBEAT_TEST_SECRET", "hunter2")
+	defer os.Unsetenv("MYSQLBEAT_TEST_SECRET")
+
+	got, err := EnvProvider{}.Resolve("MYSQLBEAT_TEST_SECRET")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "hunter2" {
+		t.Fatalf("got %q, want %q", got, "hunter2")
+	}
+}
+
+func TestEnvProviderResolveUnset(t *testing.T) {
+	if _, err := (EnvProvider{}).Resolve("MYSQLBEAT_TEST_SECRET_NOT_SET"); err == nil {
+		t.Fatal("expected an error for an unset environment variable")
+	}
+}
@@ -0,0 +1,22 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// FileProvider resolves a reference to the trimmed contents of a file on
+// disk, e.g. "file:/run/secrets/mysql_password".
+type FileProvider struct{}
+
+func (FileProvider) Resolve(ref string) (string, error) {
+	data, err := ioutil.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func init() {
+	Register("file", FileProvider{})
+}
@@ -0,0 +1,35 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileProviderResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqlbeat-secrets-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(path, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	got, err := FileProvider{}.Resolve(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "hunter2" {
+		t.Fatalf("got %q, want %q (trailing whitespace should be trimmed)", got, "hunter2")
+	}
+}
+
+func TestFileProviderResolveMissingFile(t *testing.T) {
+	if _, err := (FileProvider{}).Resolve("/no/such/path/mysqlbeat-test"); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,49 @@
+// Package secrets resolves references such as "env:MYSQL_PASSWORD" or
+// "vault:secret/data/mysql#password" into their plaintext values, so
+// mysqlbeat's config never has to hold a secret directly (or be
+// re-compiled to rotate one).
+package secrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider resolves the part of a reference after the "scheme:" prefix into
+// its secret value.
+type Provider interface {
+	Resolve(ref string) (string, error)
+}
+
+var providers = map[string]Provider{}
+
+// Register makes a Provider available under the given scheme (the part of a
+// reference before the first ":"). Registering under an already-registered
+// scheme replaces the previous provider.
+func Register(scheme string, p Provider) {
+	providers[scheme] = p
+}
+
+// Resolve splits ref into a "scheme:value" pair and dispatches to the
+// registered Provider for that scheme.
+func Resolve(ref string) (string, error) {
+	scheme, value, ok := splitRef(ref)
+	if !ok {
+		return "", fmt.Errorf("secrets: invalid reference %q, expected \"scheme:value\"", ref)
+	}
+
+	p, ok := providers[scheme]
+	if !ok {
+		return "", fmt.Errorf("secrets: no provider registered for scheme %q", scheme)
+	}
+
+	return p.Resolve(value)
+}
+
+func splitRef(ref string) (scheme, value string, ok bool) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,57 @@
+package secrets
+
+import "testing"
+
+type stubProvider struct {
+	val string
+	err error
+}
+
+func (s stubProvider) Resolve(ref string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.val + ":" + ref, nil
+}
+
+func TestResolveDispatchesToRegisteredProvider(t *testing.T) {
+	Register("stub-test-scheme", stubProvider{val: "secret"})
+
+	got, err := Resolve("stub-test-scheme:mykey")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "secret:mykey" {
+		t.Fatalf("got %q, want %q", got, "secret:mykey")
+	}
+}
+
+func TestResolveUnknownScheme(t *testing.T) {
+	if _, err := Resolve("no-such-scheme-registered:value"); err == nil {
+		t.Fatal("expected an error for an unregistered scheme")
+	}
+}
+
+func TestResolveInvalidReference(t *testing.T) {
+	if _, err := Resolve("no-colon-in-this-ref"); err == nil {
+		t.Fatal("expected an error for a reference with no \"scheme:value\" colon")
+	}
+}
+
+func TestSplitRef(t *testing.T) {
+	scheme, value, ok := splitRef("env:MYSQL_PASSWORD")
+	if !ok || scheme != "env" || value != "MYSQL_PASSWORD" {
+		t.Fatalf("got (%q, %q, %v), want (\"env\", \"MYSQL_PASSWORD\", true)", scheme, value, ok)
+	}
+
+	// Only the first colon splits, so a vault-style "path#field" value
+	// (which may itself contain colons) passes through intact.
+	scheme, value, ok = splitRef("vault:secret/data/mysql:replica#password")
+	if !ok || scheme != "vault" || value != "secret/data/mysql:replica#password" {
+		t.Fatalf("got (%q, %q, %v)", scheme, value, ok)
+	}
+
+	if _, _, ok := splitRef("no-colon"); ok {
+		t.Fatal("expected a reference with no colon to not split")
+	}
+}
@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// VaultOptions configures a VaultProvider.
+type VaultOptions struct {
+	Address string
+	// Token authenticates directly. If empty, RoleID/SecretID are used to
+	// log in via the AppRole auth method instead.
+	Token    string
+	RoleID   string
+	SecretID string
+}
+
+// VaultProvider resolves a reference of the form "<path>#<field>" (e.g.
+// "secret/data/mysql#password") against a HashiCorp Vault KV v2 engine,
+// authenticating with either a static token or AppRole.
+type VaultProvider struct {
+	client *vaultapi.Client
+}
+
+// NewVaultProvider builds a VaultProvider, logging in via AppRole when no
+// static token is supplied.
+func NewVaultProvider(opts VaultOptions) (*VaultProvider, error) {
+	cfg := vaultapi.DefaultConfig()
+	if opts.Address != "" {
+		cfg.Address = opts.Address
+	}
+
+	client, err := vaultapi.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: error creating vault client: %v", err)
+	}
+
+	switch {
+	case opts.Token != "":
+		client.SetToken(opts.Token)
+
+	case opts.RoleID != "" && opts.SecretID != "":
+		secret, err := client.Logical().Write("auth/approle/login", map[string]interface{}{
+			"role_id":   opts.RoleID,
+			"secret_id": opts.SecretID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("secrets: error logging into vault via approle: %v", err)
+		}
+		if secret == nil || secret.Auth == nil {
+			return nil, fmt.Errorf("secrets: vault approle login returned no auth info")
+		}
+		client.SetToken(secret.Auth.ClientToken)
+
+	default:
+		return nil, fmt.Errorf("secrets: vault provider requires a token or a role_id/secret_id pair")
+	}
+
+	return &VaultProvider{client: client}, nil
+}
+
+func (p *VaultProvider) Resolve(ref string) (string, error) {
+	path, field, ok := splitPathField(ref)
+	if !ok {
+		return "", fmt.Errorf("secrets: invalid vault reference %q, expected \"path#field\"", ref)
+	}
+
+	secret, err := p.client.Logical().Read(path)
+	if err != nil {
+		return "", fmt.Errorf("secrets: error reading vault path %q: %v", path, err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("secrets: vault path %q not found", path)
+	}
+
+	// KV v2 nests the actual secret data under a "data" key.
+	data, _ := secret.Data["data"].(map[string]interface{})
+	if data == nil {
+		data = secret.Data
+	}
+
+	val, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("secrets: field %q not found at vault path %q", field, path)
+	}
+
+	str, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("secrets: field %q at vault path %q is not a string", field, path)
+	}
+
+	return str, nil
+}
+
+func splitPathField(ref string) (path, field string, ok bool) {
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,20 @@
+package secrets
+
+import "testing"
+
+func TestSplitPathField(t *testing.T) {
+	path, field, ok := splitPathField("secret/data/mysql#password")
+	if !ok || path != "secret/data/mysql" || field != "password" {
+		t.Fatalf("got (%q, %q, %v), want (\"secret/data/mysql\", \"password\", true)", path, field, ok)
+	}
+
+	if _, _, ok := splitPathField("secret/data/mysql"); ok {
+		t.Fatal("expected a reference with no # to not split")
+	}
+}
+
+func TestNewVaultProviderRequiresCredentials(t *testing.T) {
+	if _, err := NewVaultProvider(VaultOptions{}); err == nil {
+		t.Fatal("expected an error when neither a token nor a role_id/secret_id pair is given")
+	}
+}